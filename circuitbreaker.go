@@ -0,0 +1,269 @@
+package mediate
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by a CircuitBreaker transport's
+// RoundTrip while the breaker is open: the request fails fast
+// without the underlying transport ever being invoked.
+var ErrCircuitOpen = errors.New("mediate: circuit breaker is open")
+
+// FailureClassifier decides whether a RoundTrip outcome counts as a
+// circuit-breaker failure, so callers can treat things like 5xx
+// responses or specific status codes as failures in addition to
+// transport errors.
+type FailureClassifier func(*http.Response, error) bool
+
+// DefaultFailureClassifier treats a transport error or any 5xx
+// response as a failure.
+func DefaultFailureClassifier(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= 500
+}
+
+// CircuitBreakerOptions configures CircuitBreaker. The zero value is
+// usable: each zero field falls back to a sane default.
+type CircuitBreakerOptions struct {
+	// Threshold is the number of failures within Window that trips
+	// the breaker from Closed to Open. Defaults to 5.
+	Threshold int
+	// Window is the rolling period over which failures are counted;
+	// failures older than Window decay out. Defaults to 10s.
+	Window time.Duration
+	// Cooldown is how long the breaker stays Open before allowing a
+	// single HalfOpen probe. Defaults to 5s.
+	Cooldown time.Duration
+	// MaxCooldown caps the cooldown after it has been doubled by
+	// repeated probe failures. Defaults to 5m.
+	MaxCooldown time.Duration
+	// Classify decides whether a RoundTrip outcome is a failure.
+	// Defaults to DefaultFailureClassifier.
+	Classify FailureClassifier
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+const failureBuckets = 10
+
+// failureWindow is a bucketed rolling counter: the window is split
+// into a fixed number of buckets and old buckets are cleared as time
+// advances past them, so failures decay instead of being counted
+// forever.
+type failureWindow struct {
+	mu        sync.Mutex
+	span      time.Duration
+	buckets   []int
+	cur       int
+	lastTouch time.Time
+}
+
+func newFailureWindow(window time.Duration, nBuckets int) *failureWindow {
+	return &failureWindow{
+		span:    window / time.Duration(nBuckets),
+		buckets: make([]int, nBuckets),
+	}
+}
+
+func (f *failureWindow) advance(now time.Time) {
+	if f.lastTouch.IsZero() {
+		f.lastTouch = now
+		return
+	}
+	elapsed := int(now.Sub(f.lastTouch) / f.span)
+	if elapsed <= 0 {
+		return
+	}
+	if elapsed >= len(f.buckets) {
+		for i := range f.buckets {
+			f.buckets[i] = 0
+		}
+	} else {
+		for i := 0; i < elapsed; i++ {
+			f.cur = (f.cur + 1) % len(f.buckets)
+			f.buckets[f.cur] = 0
+		}
+		// Keep bucket boundaries aligned so the unelapsed part of
+		// the current span isn't lost on every advance.
+		f.lastTouch = f.lastTouch.Add(time.Duration(elapsed) * f.span)
+		return
+	}
+	f.lastTouch = now
+}
+
+func (f *failureWindow) record(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.advance(now)
+	f.buckets[f.cur]++
+}
+
+func (f *failureWindow) count(now time.Time) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.advance(now)
+	total := 0
+	for _, c := range f.buckets {
+		total += c
+	}
+	return total
+}
+
+func (f *failureWindow) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.buckets {
+		f.buckets[i] = 0
+	}
+}
+
+type circuitBreaker struct {
+	transport   http.RoundTripper
+	classify    FailureClassifier
+	threshold   int
+	cooldown    time.Duration
+	maxCooldown time.Duration
+	failures    *failureWindow
+
+	mu          sync.Mutex
+	state       circuitState
+	openedAt    time.Time
+	curCooldown time.Duration
+}
+
+// CircuitBreaker builds a RoundTripper implementing the classic
+// three-state breaker over transport: Closed (requests pass through
+// and failures are counted in a rolling window), Open (requests fail
+// fast with ErrCircuitOpen, without touching transport, for a
+// cooldown period), and HalfOpen (a single probe request is let
+// through once the cooldown elapses - success closes the breaker,
+// failure re-opens it and doubles the cooldown, up to MaxCooldown).
+//
+// This composes naturally in front of RetryPolicy, so retries stop
+// hammering a backend the breaker has already given up on.
+func CircuitBreaker(transport http.RoundTripper, opts CircuitBreakerOptions) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	threshold := opts.Threshold
+	if threshold <= 0 {
+		threshold = 5
+	}
+	window := opts.Window
+	if window <= 0 {
+		window = 10 * time.Second
+	}
+	cooldown := opts.Cooldown
+	if cooldown <= 0 {
+		cooldown = 5 * time.Second
+	}
+	maxCooldown := opts.MaxCooldown
+	if maxCooldown <= 0 {
+		maxCooldown = 5 * time.Minute
+	}
+	classify := opts.Classify
+	if classify == nil {
+		classify = DefaultFailureClassifier
+	}
+	return &circuitBreaker{
+		transport:   transport,
+		classify:    classify,
+		threshold:   threshold,
+		cooldown:    cooldown,
+		maxCooldown: maxCooldown,
+		curCooldown: cooldown,
+		failures:    newFailureWindow(window, failureBuckets),
+		state:       circuitClosed,
+	}
+}
+
+func (c *circuitBreaker) CancelRequest(req *http.Request) {
+	tr, ok := c.transport.(canceler)
+	if ok {
+		tr.CancelRequest(req)
+	}
+}
+
+func (c *circuitBreaker) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !c.allow() {
+		return nil, ErrCircuitOpen
+	}
+	resp, err := c.transport.RoundTrip(req)
+	c.report(c.classify(resp, err))
+	return resp, err
+}
+
+// allow reports whether a request may proceed to the underlying
+// transport, advancing Open to HalfOpen once the cooldown has
+// elapsed and admitting exactly one probe while HalfOpen.
+func (c *circuitBreaker) allow() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch c.state {
+	case circuitOpen:
+		if time.Since(c.openedAt) < c.curCooldown {
+			return false
+		}
+		c.state = circuitHalfOpen
+		return true
+	case circuitHalfOpen:
+		return false
+	default:
+		return true
+	}
+}
+
+// report records the outcome of a request that was allowed through,
+// tripping or resetting the breaker as needed.
+func (c *circuitBreaker) report(failed bool) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == circuitHalfOpen {
+		if failed {
+			c.open(now, true)
+		} else {
+			c.state = circuitClosed
+			c.curCooldown = c.cooldown
+			c.failures.reset()
+		}
+		return
+	}
+
+	if !failed {
+		return
+	}
+	c.failures.record(now)
+	if c.failures.count(now) >= c.threshold {
+		c.open(now, false)
+	}
+}
+
+// open trips the breaker. probeFailed indicates this is a HalfOpen
+// probe failure, which doubles the cooldown rather than resetting it
+// to the base value.
+func (c *circuitBreaker) open(now time.Time, probeFailed bool) {
+	if probeFailed {
+		c.curCooldown *= 2
+		if c.curCooldown > c.maxCooldown {
+			c.curCooldown = c.maxCooldown
+		}
+	} else {
+		c.curCooldown = c.cooldown
+	}
+	c.state = circuitOpen
+	c.openedAt = now
+	c.failures.reset()
+}
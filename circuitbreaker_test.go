@@ -0,0 +1,125 @@
+package mediate
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type fnTransport struct {
+	fn    func(*http.Request) (*http.Response, error)
+	calls int
+}
+
+func (f *fnTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.calls++
+	return f.fn(req)
+}
+
+func TestDefaultFailureClassifier(t *testing.T) {
+	if !DefaultFailureClassifier(nil, errors.New("boom")) {
+		t.Errorf("transport error should be a failure")
+	}
+	if DefaultFailureClassifier(&http.Response{StatusCode: 200}, nil) {
+		t.Errorf("200 should not be a failure")
+	}
+	if !DefaultFailureClassifier(&http.Response{StatusCode: 503}, nil) {
+		t.Errorf("503 should be a failure")
+	}
+}
+
+func TestFailureWindowDecay(t *testing.T) {
+	fw := newFailureWindow(100*time.Millisecond, 10)
+	now := time.Now()
+	fw.record(now)
+	fw.record(now)
+	if got := fw.count(now); got != 2 {
+		t.Fatalf("got %d, want 2", got)
+	}
+	later := now.Add(200 * time.Millisecond)
+	if got := fw.count(later); got != 0 {
+		t.Fatalf("got %d, want 0 once the window has fully elapsed", got)
+	}
+}
+
+func TestCircuitBreakerOpenFailsFastWithoutCallingTransport(t *testing.T) {
+	ft := &fnTransport{fn: func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("boom")
+	}}
+	cb := CircuitBreaker(ft, CircuitBreakerOptions{Threshold: 2, Window: time.Second, Cooldown: time.Hour})
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+
+	for i := 0; i < 2; i++ {
+		if _, err := cb.RoundTrip(req); err == nil {
+			t.Fatalf("attempt %d: expected the underlying transport error", i)
+		}
+	}
+	if ft.calls != 2 {
+		t.Fatalf("got %d calls before trip, want 2", ft.calls)
+	}
+
+	_, err := cb.RoundTrip(req)
+	if err != ErrCircuitOpen {
+		t.Fatalf("got %v, want ErrCircuitOpen", err)
+	}
+	if ft.calls != 2 {
+		t.Fatalf("expected an open breaker not to call the underlying transport, got %d calls", ft.calls)
+	}
+}
+
+func TestCircuitBreakerHalfOpenProbeCloses(t *testing.T) {
+	failing := true
+	ft := &fnTransport{}
+	ft.fn = func(*http.Request) (*http.Response, error) {
+		if failing {
+			return nil, errors.New("boom")
+		}
+		return &http.Response{StatusCode: 200, Body: http.NoBody, Header: make(http.Header)}, nil
+	}
+	cb := CircuitBreaker(ft, CircuitBreakerOptions{Threshold: 1, Window: time.Second, Cooldown: 20 * time.Millisecond})
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+
+	cb.RoundTrip(req) // single failure trips the breaker open
+	if _, err := cb.RoundTrip(req); err != ErrCircuitOpen {
+		t.Fatalf("expected ErrCircuitOpen immediately after tripping")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	failing = false
+	if _, err := cb.RoundTrip(req); err != nil {
+		t.Fatalf("expected the half-open probe to succeed, got %v", err)
+	}
+
+	// A successful probe should close the breaker, so the next
+	// request reaches the underlying transport instead of failing
+	// fast.
+	failing = true
+	if _, err := cb.RoundTrip(req); err == ErrCircuitOpen {
+		t.Fatalf("breaker still failing fast after a successful probe; should have closed")
+	}
+}
+
+func TestCircuitBreakerHalfOpenProbeFailureDoublesCooldown(t *testing.T) {
+	ft := &fnTransport{fn: func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("boom")
+	}}
+	cb := CircuitBreaker(ft, CircuitBreakerOptions{
+		Threshold:   1,
+		Window:      time.Second,
+		Cooldown:    10 * time.Millisecond,
+		MaxCooldown: time.Second,
+	}).(*circuitBreaker)
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+
+	cb.RoundTrip(req) // trips open with a 10ms cooldown
+	time.Sleep(15 * time.Millisecond)
+	cb.RoundTrip(req) // half-open probe fails, cooldown should double to 20ms
+
+	cb.mu.Lock()
+	got := cb.curCooldown
+	cb.mu.Unlock()
+	if got != 20*time.Millisecond {
+		t.Fatalf("got cooldown %v, want 20ms", got)
+	}
+}
@@ -0,0 +1,86 @@
+package mediate
+
+import (
+	"net/http"
+	"sync"
+)
+
+type maxInFlight struct {
+	transport http.RoundTripper
+	sem       chan struct{}
+}
+
+// MaxInFlight builds a RoundTripper which enforces a hard ceiling on
+// the number of concurrent RoundTrip calls in progress against
+// transport, independent of any per-second rate - similar in spirit
+// to HTTP/2's StrictMaxConcurrentStreams. Requests beyond the
+// ceiling block on a bounded semaphore (a buffered channel of size
+// n), selecting on req.Context().Done() so a slow server cannot
+// cause unbounded goroutine growth in the caller.
+func MaxInFlight(n int, transport http.RoundTripper) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &maxInFlight{transport: transport, sem: make(chan struct{}, n)}
+}
+
+func (t *maxInFlight) CancelRequest(req *http.Request) {
+	tr, ok := t.transport.(canceler)
+	if ok {
+		tr.CancelRequest(req)
+	}
+}
+
+func (t *maxInFlight) RoundTrip(req *http.Request) (*http.Response, error) {
+	select {
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	case t.sem <- struct{}{}:
+	}
+	defer func() { <-t.sem }()
+	return t.transport.RoundTrip(req)
+}
+
+/////////////////////////
+
+type maxInFlightPerHost struct {
+	transport http.RoundTripper
+	n         int
+	hosts     sync.Map // host (string) -> chan struct{}
+}
+
+// MaxInFlightPerHost is MaxInFlight keyed off req.URL.Host rather
+// than enforced globally, since a single shared ceiling is often too
+// coarse for a client that fans out to many backends.
+func MaxInFlightPerHost(n int, transport http.RoundTripper) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &maxInFlightPerHost{transport: transport, n: n}
+}
+
+func (t *maxInFlightPerHost) CancelRequest(req *http.Request) {
+	tr, ok := t.transport.(canceler)
+	if ok {
+		tr.CancelRequest(req)
+	}
+}
+
+func (t *maxInFlightPerHost) semaphore(host string) chan struct{} {
+	if sem, ok := t.hosts.Load(host); ok {
+		return sem.(chan struct{})
+	}
+	sem, _ := t.hosts.LoadOrStore(host, make(chan struct{}, t.n))
+	return sem.(chan struct{})
+}
+
+func (t *maxInFlightPerHost) RoundTrip(req *http.Request) (*http.Response, error) {
+	sem := t.semaphore(req.URL.Host)
+	select {
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	case sem <- struct{}{}:
+	}
+	defer func() { <-sem }()
+	return t.transport.RoundTrip(req)
+}
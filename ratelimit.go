@@ -0,0 +1,179 @@
+package mediate
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// tokenBucket is a standard token-bucket rate limiter: tokens
+// accumulate continuously at refillRate tokens/sec up to capacity,
+// rather than being handed out in fixed quantums and dropped between
+// ticks.
+type tokenBucket struct {
+	mu         sync.Mutex
+	capacity   float64
+	refillRate float64
+	tokens     float64
+	last       time.Time
+}
+
+func newTokenBucket(capacity int, refillRate float64) *tokenBucket {
+	return &tokenBucket{
+		capacity:   float64(capacity),
+		refillRate: refillRate,
+		tokens:     float64(capacity),
+		last:       time.Now(),
+	}
+}
+
+// take blocks, respecting ctx, until a token is available, then
+// consumes it.
+func (b *tokenBucket) take(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens += now.Sub(b.last).Seconds() * b.refillRate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - b.tokens) / b.refillRate * float64(time.Second))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+type rateLimit struct {
+	transport http.RoundTripper
+	bucket    *tokenBucket
+}
+
+// RateLimit builds a RoundTripper which permits up to requests
+// through every "every" duration to the passed transport, backed by
+// a token bucket with that rate as its refill rate and requests as
+// its burst capacity. Requests that arrive once the bucket is empty
+// block until enough tokens have accumulated, selecting on
+// req.Context().Done() so a canceled request doesn't keep waiting.
+func RateLimit(requests int, every time.Duration, transport http.RoundTripper) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	rps := float64(requests) / every.Seconds()
+	return &rateLimit{transport: transport, bucket: newTokenBucket(requests, rps)}
+}
+
+func (r *rateLimit) CancelRequest(req *http.Request) {
+	tr, ok := r.transport.(canceler)
+	if ok {
+		tr.CancelRequest(req)
+	}
+}
+
+func (r *rateLimit) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := r.bucket.take(req.Context()); err != nil {
+		return nil, err
+	}
+	return r.transport.RoundTrip(req)
+}
+
+/////////////////////////
+
+type hostBucket struct {
+	bucket   *tokenBucket
+	lastUsed int64 // unix nanos, accessed atomically
+}
+
+type rateLimitPerHost struct {
+	transport http.RoundTripper
+	rps       float64
+	burst     int
+	idleTTL   time.Duration
+	hosts     sync.Map // host (string) -> *hostBucket
+	done      chan struct{}
+}
+
+// RateLimitPerHost builds a RoundTripper which maintains one
+// rps/burst token bucket per req.URL.Host rather than a single
+// global bucket, since a shared cap is often too coarse for a client
+// that fans out to many backends. Buckets for hosts that have gone
+// unused for idleTTL are evicted so a long-lived client doesn't
+// accumulate one forever; pass idleTTL <= 0 to disable eviction.
+//
+// When idleTTL > 0, eviction runs on a background goroutine. Callers
+// that don't keep the returned RoundTripper for the lifetime of the
+// process should type-assert it to an interface{ Close() } and call
+// Close to stop that goroutine.
+func RateLimitPerHost(rps float64, burst int, idleTTL time.Duration, transport http.RoundTripper) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	r := &rateLimitPerHost{transport: transport, rps: rps, burst: burst, idleTTL: idleTTL, done: make(chan struct{})}
+	if idleTTL > 0 {
+		go r.evictIdle()
+	}
+	return r
+}
+
+// Close stops the background goroutine that evicts idle host
+// buckets. It is a no-op if idleTTL was <= 0.
+func (r *rateLimitPerHost) Close() {
+	close(r.done)
+}
+
+func (r *rateLimitPerHost) CancelRequest(req *http.Request) {
+	tr, ok := r.transport.(canceler)
+	if ok {
+		tr.CancelRequest(req)
+	}
+}
+
+func (r *rateLimitPerHost) bucketFor(host string) *hostBucket {
+	if hb, ok := r.hosts.Load(host); ok {
+		return hb.(*hostBucket)
+	}
+	hb, _ := r.hosts.LoadOrStore(host, &hostBucket{bucket: newTokenBucket(r.burst, r.rps)})
+	return hb.(*hostBucket)
+}
+
+func (r *rateLimitPerHost) RoundTrip(req *http.Request) (*http.Response, error) {
+	hb := r.bucketFor(req.URL.Host)
+	atomic.StoreInt64(&hb.lastUsed, time.Now().UnixNano())
+	if err := hb.bucket.take(req.Context()); err != nil {
+		return nil, err
+	}
+	return r.transport.RoundTrip(req)
+}
+
+func (r *rateLimitPerHost) evictIdle() {
+	tick := time.NewTicker(r.idleTTL)
+	defer tick.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-tick.C:
+			cutoff := time.Now().Add(-r.idleTTL).UnixNano()
+			r.hosts.Range(func(key, value interface{}) bool {
+				if atomic.LoadInt64(&value.(*hostBucket).lastUsed) < cutoff {
+					r.hosts.Delete(key)
+				}
+				return true
+			})
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package mediate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+)
+
+type reliableBody struct {
+	transport http.RoundTripper
+}
+
+// ReliableBody builds a RoundTripper which will consume all
+// of the response Body into a new memory buffer, and returns
+// the response with this alternate Body.
+//
+// This is less memory efficient compared to streaming the response
+// from the socket directly, but allows API to work with complete
+// operations making retries and other actions trivial.
+//
+// The buffering read aborts with req.Context().Err() if the request's
+// context is canceled before the body is fully drained. For
+// responses whose size isn't bounded in advance, prefer
+// ReliableBodyLimit.
+func ReliableBody(transport http.RoundTripper) http.RoundTripper {
+	return &reliableBody{transport}
+}
+
+func (t *reliableBody) CancelRequest(req *http.Request) {
+	tr, ok := t.transport.(canceler)
+	if ok {
+		tr.CancelRequest(req)
+	}
+}
+
+func (t *reliableBody) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(ctxReader{ctx: req.Context(), r: resp.Body})
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewReader(body)
+	resp.Body = ioutil.NopCloser(buf)
+	return resp, nil
+}
+
+/////////////////////////
+
+// errBodyTooLarge is returned by ReliableBodyLimit when a response
+// exceeds maxInMemory and no spillDir was configured to overflow to.
+var errBodyTooLarge = errors.New("mediate: response body exceeds maxInMemory and no spillDir was configured")
+
+type reliableBodyLimit struct {
+	transport   http.RoundTripper
+	maxInMemory int64
+	spillDir    string
+}
+
+// ReliableBodyLimit is ReliableBody with a cap on how much of the
+// response it will hold in memory. Up to maxInMemory bytes are
+// buffered directly; a response bigger than that transparently
+// overflows into a temp file under spillDir instead of risking an
+// OOM on a large or malicious response. If maxInMemory is exceeded
+// and spillDir is empty, RoundTrip returns an error rather than
+// buffering the rest unbounded.
+//
+// The returned resp.Body is an io.ReadCloser that also implements
+// io.Seeker, so retry logic layered on top can re-read a buffered
+// response; for a spilled response, Close removes the temp file. The
+// copy honors req.Context(), so a canceled request doesn't keep
+// draining the socket.
+func ReliableBodyLimit(maxInMemory int64, spillDir string, transport http.RoundTripper) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &reliableBodyLimit{transport: transport, maxInMemory: maxInMemory, spillDir: spillDir}
+}
+
+func (t *reliableBodyLimit) CancelRequest(req *http.Request) {
+	tr, ok := t.transport.(canceler)
+	if ok {
+		tr.CancelRequest(req)
+	}
+}
+
+func (t *reliableBodyLimit) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := spillingCopy(req.Context(), resp.Body, t.maxInMemory, t.spillDir)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = body
+	return resp, nil
+}
+
+// spillBody is the io.ReadCloser (and io.Seeker) handed back by
+// ReliableBodyLimit. Small responses are served out of an in-memory
+// buffer; larger ones out of a temp file that Close removes.
+type spillBody struct {
+	mem  *bytes.Reader
+	file *os.File
+}
+
+func (s *spillBody) Read(p []byte) (int, error) {
+	if s.file != nil {
+		return s.file.Read(p)
+	}
+	return s.mem.Read(p)
+}
+
+func (s *spillBody) Seek(offset int64, whence int) (int64, error) {
+	if s.file != nil {
+		return s.file.Seek(offset, whence)
+	}
+	return s.mem.Seek(offset, whence)
+}
+
+func (s *spillBody) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	name := s.file.Name()
+	err := s.file.Close()
+	if rmErr := os.Remove(name); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+// spillingCopy drains r (honoring ctx) into memory up to maxInMemory
+// bytes. If r holds no more than that, the result is served from the
+// in-memory buffer; otherwise the buffered prefix and the remainder
+// of r are copied into a temp file under spillDir.
+func spillingCopy(ctx context.Context, r io.Reader, maxInMemory int64, spillDir string) (io.ReadCloser, error) {
+	cr := ctxReader{ctx: ctx, r: r}
+
+	var buf bytes.Buffer
+	_, err := io.CopyN(&buf, cr, maxInMemory+1)
+	if err == io.EOF {
+		return &spillBody{mem: bytes.NewReader(buf.Bytes())}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if spillDir == "" {
+		return nil, errBodyTooLarge
+	}
+	f, err := ioutil.TempFile(spillDir, "mediate-reliablebody-")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(f, io.MultiReader(bytes.NewReader(buf.Bytes()), cr)); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &spillBody{file: f}, nil
+}
@@ -0,0 +1,98 @@
+package mediate
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSpillingCopyFitsInMemory(t *testing.T) {
+	data := "hello world"
+	rc, err := spillingCopy(context.Background(), strings.NewReader(data), int64(len(data)), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer rc.Close()
+
+	if rc.(*spillBody).file != nil {
+		t.Fatalf("expected an in-memory body when the response exactly fits maxInMemory")
+	}
+	got, _ := ioutil.ReadAll(rc)
+	if string(got) != data {
+		t.Fatalf("got %q, want %q", got, data)
+	}
+}
+
+func TestSpillingCopyExceedsMemorySpillsToDisk(t *testing.T) {
+	dir := t.TempDir()
+	data := "hello world"
+	rc, err := spillingCopy(context.Background(), strings.NewReader(data), int64(len(data))-1, dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sb := rc.(*spillBody)
+	if sb.file == nil {
+		t.Fatalf("expected a spilled file when the response exceeds maxInMemory")
+	}
+	got, _ := ioutil.ReadAll(rc)
+	if string(got) != data {
+		t.Fatalf("got %q, want %q", got, data)
+	}
+
+	name := sb.file.Name()
+	if err := rc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Fatalf("expected the temp file to be removed after Close")
+	}
+}
+
+func TestSpillingCopyExceedsMemoryWithNoSpillDirErrors(t *testing.T) {
+	data := "hello world"
+	_, err := spillingCopy(context.Background(), strings.NewReader(data), int64(len(data))-1, "")
+	if err != errBodyTooLarge {
+		t.Fatalf("got %v, want errBodyTooLarge", err)
+	}
+}
+
+func TestSpillingCopySpilledBodyIsSeekable(t *testing.T) {
+	dir := t.TempDir()
+	data := "0123456789"
+	rc, err := spillingCopy(context.Background(), strings.NewReader(data), 2, dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer rc.Close()
+
+	if _, err := rc.(io.Seeker).Seek(5, io.SeekStart); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	got, _ := ioutil.ReadAll(rc)
+	if string(got) != data[5:] {
+		t.Fatalf("got %q, want %q", got, data[5:])
+	}
+}
+
+func TestSpillingCopyHonorsCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := spillingCopy(ctx, strings.NewReader("hello"), 100, "")
+	if err != context.Canceled {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestReliableBodyLimitDefaultsNilTransport(t *testing.T) {
+	// Regression test: ReliableBodyLimit used to store a nil
+	// transport verbatim, panicking on the first RoundTrip.
+	tr := ReliableBodyLimit(1024, "", nil).(*reliableBodyLimit)
+	if tr.transport == nil {
+		t.Fatalf("expected a nil transport to default to http.DefaultTransport")
+	}
+}
@@ -0,0 +1,209 @@
+package mediate
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultRetryStatusCodes is used when RetryPolicy is called with a
+// nil statusCodes list.
+var defaultRetryStatusCodes = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+type retryPolicy struct {
+	transport   http.RoundTripper
+	maxRetries  int
+	retryStatus map[int]bool
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+// RetryPolicy builds a RoundTripper which retries a request up to
+// maxRetries times on transport errors, or on any response whose
+// status code is in statusCodes (pass nil to retry the default set:
+// 429, 502, 503 and 504).
+//
+// The request body is buffered once - via
+// req.GetBody when the stdlib populated it, otherwise by reading and
+// rewrapping req.Body in a bytes.Reader - and re-seated on every
+// attempt, so a POST can be retried safely.
+//
+// The delay between attempts is exponential backoff with full
+// jitter, based on baseDelay and capped at maxDelay, unless the
+// response carries a Retry-After header, which is honored as an
+// override; both its forms (delay-seconds and HTTP-date) are
+// understood. The wait selects on req.Context().Done(), so a
+// canceled request does not keep sleeping.
+func RetryPolicy(transport http.RoundTripper, maxRetries int, statusCodes []int, baseDelay, maxDelay time.Duration) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	retryStatus := defaultRetryStatusCodes
+	if len(statusCodes) > 0 {
+		retryStatus = make(map[int]bool, len(statusCodes))
+		for _, code := range statusCodes {
+			retryStatus[code] = true
+		}
+	}
+	return &retryPolicy{
+		transport:   transport,
+		maxRetries:  maxRetries,
+		retryStatus: retryStatus,
+		baseDelay:   baseDelay,
+		maxDelay:    maxDelay,
+	}
+}
+
+// FixedRetries will issue the same request up to count times, if an
+// explicit error (socket error, transport error) is returned from the
+// underlying RoundTripper, or the response carries one of the classic
+// transient status codes (429, 502, 503, 504). It is a thin,
+// no-backoff wrapper over RetryPolicy kept for backwards
+// compatibility; new callers should prefer RetryPolicy directly.
+//
+// RetryPolicy's maxRetries counts retries after the first attempt,
+// so count total attempts means maxRetries = count-1.
+func FixedRetries(count int, transport http.RoundTripper) http.RoundTripper {
+	maxRetries := count - 1
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	return RetryPolicy(transport, maxRetries, nil, 0, 0)
+}
+
+func (t *retryPolicy) CancelRequest(req *http.Request) {
+	tr, ok := t.transport.(canceler)
+	if ok {
+		tr.CancelRequest(req)
+	}
+}
+
+func (t *retryPolicy) RoundTrip(req *http.Request) (*http.Response, error) {
+	getBody, err := bodyGetter(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *http.Response
+	var lastErr error
+	for attempt := 0; attempt <= t.maxRetries; attempt++ {
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		default:
+		}
+
+		nreq := cloneRequest(req)
+		if getBody != nil {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			nreq.Body = body
+		}
+
+		resp, lastErr = t.transport.RoundTrip(nreq)
+		if lastErr == nil && !t.retryStatus[resp.StatusCode] {
+			return resp, nil
+		}
+		if attempt == t.maxRetries {
+			break
+		}
+
+		delay := t.backoff(attempt)
+		if lastErr == nil {
+			if d, ok := retryAfter(resp); ok {
+				delay = d
+			}
+			resp.Body.Close()
+		}
+		if !t.sleep(req, delay) {
+			return nil, req.Context().Err()
+		}
+	}
+	return resp, lastErr
+}
+
+// sleep waits out delay, returning false if req's context is
+// canceled first.
+func (t *retryPolicy) sleep(req *http.Request, delay time.Duration) bool {
+	if delay <= 0 {
+		select {
+		case <-req.Context().Done():
+			return false
+		default:
+			return true
+		}
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-req.Context().Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// backoff computes the exponential-backoff-with-full-jitter delay
+// for the given (zero-indexed) attempt.
+func (t *retryPolicy) backoff(attempt int) time.Duration {
+	if t.baseDelay <= 0 {
+		return 0
+	}
+	max := t.maxDelay
+	if max <= 0 {
+		max = t.baseDelay
+	}
+	d := time.Duration(float64(t.baseDelay) * math.Pow(2, float64(attempt)))
+	if d <= 0 || d > max {
+		d = max
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// bodyGetter returns a function that produces a fresh copy of req's
+// body for each retry attempt, buffering it into memory the first
+// time it is needed. It returns a nil function for bodyless requests.
+func bodyGetter(req *http.Request) (func() (io.ReadCloser, error), error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		return req.GetBody, nil
+	}
+	buf, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(buf)), nil
+	}, nil
+}
+
+// retryAfter parses the Retry-After header in either its
+// delay-seconds or HTTP-date form.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+	if when, err := http.ParseTime(v); err == nil {
+		return time.Until(when), true
+	}
+	return 0, false
+}
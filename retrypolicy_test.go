@@ -0,0 +1,144 @@
+package mediate
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type countingTransport struct {
+	fn    func(*http.Request) (*http.Response, error)
+	calls int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.calls++
+	return c.fn(req)
+}
+
+func TestFixedRetriesAttemptCount(t *testing.T) {
+	for _, count := range []int{1, 2, 5} {
+		ct := &countingTransport{fn: func(*http.Request) (*http.Response, error) {
+			return nil, errors.New("boom")
+		}}
+		tr := FixedRetries(count, ct)
+		req, _ := http.NewRequest("GET", "http://example.com", nil)
+		tr.RoundTrip(req)
+		if ct.calls != count {
+			t.Errorf("FixedRetries(%d): got %d attempts, want %d", count, ct.calls, count)
+		}
+	}
+}
+
+func TestRetryPolicyRetriesOnStatusCode(t *testing.T) {
+	ct := &countingTransport{}
+	ct.fn = func(*http.Request) (*http.Response, error) {
+		if ct.calls < 3 {
+			return &http.Response{StatusCode: 503, Body: http.NoBody, Header: make(http.Header)}, nil
+		}
+		return &http.Response{StatusCode: 200, Body: http.NoBody, Header: make(http.Header)}, nil
+	}
+	tr := RetryPolicy(ct, 5, nil, time.Millisecond, 5*time.Millisecond)
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("got status %d, want 200", resp.StatusCode)
+	}
+	if ct.calls != 3 {
+		t.Fatalf("got %d attempts, want 3", ct.calls)
+	}
+}
+
+func TestRetryPolicyStopsOnNonRetryStatus(t *testing.T) {
+	ct := &countingTransport{fn: func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 404, Body: http.NoBody, Header: make(http.Header)}, nil
+	}}
+	tr := RetryPolicy(ct, 5, nil, time.Millisecond, 5*time.Millisecond)
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != 404 {
+		t.Fatalf("got status %d, want 404", resp.StatusCode)
+	}
+	if ct.calls != 1 {
+		t.Fatalf("got %d attempts, want 1 (404 isn't retried by default)", ct.calls)
+	}
+}
+
+func TestRetryPolicyResendsBody(t *testing.T) {
+	var seen []string
+	ct := &countingTransport{}
+	ct.fn = func(req *http.Request) (*http.Response, error) {
+		b, _ := ioutil.ReadAll(req.Body)
+		seen = append(seen, string(b))
+		if len(seen) < 2 {
+			return nil, errors.New("boom")
+		}
+		return &http.Response{StatusCode: 200, Body: http.NoBody, Header: make(http.Header)}, nil
+	}
+	tr := RetryPolicy(ct, 3, nil, time.Millisecond, time.Millisecond)
+	req, _ := http.NewRequest("POST", "http://example.com", strings.NewReader("payload"))
+
+	if _, err := tr.RoundTrip(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(seen) != 2 || seen[0] != "payload" || seen[1] != "payload" {
+		t.Fatalf("got %#v, want the body resent unchanged on retry", seen)
+	}
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	resp := &http.Response{Header: make(http.Header)}
+	resp.Header.Set("Retry-After", "2")
+	d, ok := retryAfter(resp)
+	if !ok || d != 2*time.Second {
+		t.Fatalf("got (%v, %v), want (2s, true)", d, ok)
+	}
+}
+
+func TestRetryAfterHTTPDate(t *testing.T) {
+	when := time.Now().Add(3 * time.Second).UTC()
+	resp := &http.Response{Header: make(http.Header)}
+	resp.Header.Set("Retry-After", when.Format(http.TimeFormat))
+
+	d, ok := retryAfter(resp)
+	if !ok {
+		t.Fatalf("expected ok")
+	}
+	if d < 2*time.Second || d > 4*time.Second {
+		t.Fatalf("got %v, want ~3s", d)
+	}
+}
+
+func TestRetryAfterMissing(t *testing.T) {
+	resp := &http.Response{Header: make(http.Header)}
+	if _, ok := retryAfter(resp); ok {
+		t.Fatalf("expected ok=false with no Retry-After header")
+	}
+}
+
+func TestRetryPolicyBackoffCapped(t *testing.T) {
+	rp := &retryPolicy{baseDelay: 10 * time.Millisecond, maxDelay: 20 * time.Millisecond}
+	for attempt := 0; attempt < 10; attempt++ {
+		if d := rp.backoff(attempt); d > 20*time.Millisecond {
+			t.Fatalf("backoff(%d) = %v, exceeds maxDelay", attempt, d)
+		}
+	}
+}
+
+func TestRetryPolicyBackoffZeroBaseDelayMeansNoWait(t *testing.T) {
+	rp := &retryPolicy{}
+	if d := rp.backoff(3); d != 0 {
+		t.Fatalf("got %v, want 0 with baseDelay unset", d)
+	}
+}